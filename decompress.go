@@ -0,0 +1,215 @@
+// Copyright © 2025 Genome Research Limited
+// Authors:
+//  Sendu Bala <sb10@sanger.ac.uk>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Names accepted by -decompressor, forcing a specific backend instead of
+// sniffing the file's magic bytes.
+const (
+	decompressorAuto  = ""
+	decompressorPigz  = "pigz"
+	decompressorGzip  = "gzip"
+	decompressorZstd  = "zstd"
+	decompressorPlain = "plain"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}             //nolint:gochecknoglobals
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd} //nolint:gochecknoglobals
+
+// Decompressor opens a stats file, decompressing it if necessary.
+type Decompressor interface {
+	// Open returns a reader over path's decompressed content, and a cleanup
+	// function that must be called once reading is finished (eg. to reap a
+	// child process).
+	Open(path string) (io.ReadCloser, func() error, error)
+}
+
+// decompressorFor returns the Decompressor to use for path: name if it's one
+// of the decompressorXxx constants, or one picked by sniffing path's magic
+// bytes if name is decompressorAuto.
+func decompressorFor(path, name string) (Decompressor, error) {
+	if name != decompressorAuto {
+		return namedDecompressor(name)
+	}
+
+	magic, err := readMagic(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		if _, err := exec.LookPath("pigz"); err == nil {
+			return pigzDecompressor{}, nil
+		}
+
+		return gzipDecompressor{}, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		return zstdDecompressor{}, nil
+	default:
+		return plainDecompressor{}, nil
+	}
+}
+
+func namedDecompressor(name string) (Decompressor, error) {
+	switch name {
+	case decompressorPigz:
+		return pigzDecompressor{}, nil
+	case decompressorGzip:
+		return gzipDecompressor{}, nil
+	case decompressorZstd:
+		return zstdDecompressor{}, nil
+	case decompressorPlain:
+		return plainDecompressor{}, nil
+	default:
+		return nil, fmt.Errorf("unknown -decompressor %q", name)
+	}
+}
+
+// readMagic returns up to the first len(zstdMagic) bytes of path, without
+// error if the file is shorter than that.
+func readMagic(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	magic := make([]byte, len(zstdMagic))
+
+	n, err := io.ReadFull(f, magic)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+
+	return magic[:n], nil
+}
+
+// pigzDecompressor shells out to the external pigz binary, which is
+// typically faster than compress/gzip for large files.
+type pigzDecompressor struct{}
+
+func (pigzDecompressor) Open(path string) (io.ReadCloser, func() error, error) {
+	cmd := exec.Command("pigz", "-d", "-c", path)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+
+	cleanup := func() error {
+		return cmd.Wait()
+	}
+
+	return stdout, cleanup, nil
+}
+
+// gzipDecompressor is a pure Go fallback for when pigz isn't installed.
+type gzipDecompressor struct{}
+
+func (gzipDecompressor) Open(path string) (io.ReadCloser, func() error, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+
+		return nil, nil, err
+	}
+
+	return &multiCloser{Reader: gr, closers: []io.Closer{gr, f}}, noCleanup, nil
+}
+
+// zstdDecompressor is a pure Go implementation, since there is no ubiquitous
+// external zstd decompressor equivalent to pigz.
+type zstdDecompressor struct{}
+
+func (zstdDecompressor) Open(path string) (io.ReadCloser, func() error, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	zr, err := zstd.NewReader(f)
+	if err != nil {
+		f.Close()
+
+		return nil, nil, err
+	}
+
+	rc := zr.IOReadCloser()
+
+	return &multiCloser{Reader: rc, closers: []io.Closer{rc, f}}, noCleanup, nil
+}
+
+// plainDecompressor reads uncompressed stats data as-is, letting the parser
+// be used as a library without requiring any compression at all.
+type plainDecompressor struct{}
+
+func (plainDecompressor) Open(path string) (io.ReadCloser, func() error, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return f, noCleanup, nil
+}
+
+func noCleanup() error { return nil }
+
+// multiCloser lets Close() close several underlying closers, such as a
+// compression reader and the file it wraps.
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m *multiCloser) Close() error {
+	var firstErr error
+
+	for _, c := range m.closers {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
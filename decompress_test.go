@@ -0,0 +1,178 @@
+// Copyright © 2025 Genome Research Limited
+// Authors:
+//  Sendu Bala <sb10@sanger.ac.uk>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const decompressTestContent = "hello, condapaths\n"
+
+func writeGzipFile(t *testing.T, path string) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	if _, err := gw.Write([]byte(decompressTestContent)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeZstdFile(t *testing.T, path string) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	zw, err := zstd.NewWriter(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := zw.Write([]byte(decompressTestContent)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writePlainFile(t *testing.T, path string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(decompressTestContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readAll(t *testing.T, d Decompressor, path string) string {
+	t.Helper()
+
+	rc, cleanup, err := d.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cleanup(); err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func TestDecompressorFor(t *testing.T) {
+	dir := t.TempDir()
+
+	Convey("A gzip file is auto-detected and decompressed", t, func() {
+		path := filepath.Join(dir, "a.stats.gz")
+		writeGzipFile(t, path)
+
+		d, err := decompressorFor(path, decompressorAuto)
+		So(err, ShouldBeNil)
+		So(readAll(t, d, path), ShouldEqual, decompressTestContent)
+	})
+
+	Convey("A zstd file is auto-detected and decompressed", t, func() {
+		path := filepath.Join(dir, "a.stats.zst")
+		writeZstdFile(t, path)
+
+		d, err := decompressorFor(path, decompressorAuto)
+		So(err, ShouldBeNil)
+		So(d, ShouldHaveSameTypeAs, zstdDecompressor{})
+		So(readAll(t, d, path), ShouldEqual, decompressTestContent)
+	})
+
+	Convey("An uncompressed file is auto-detected and read as-is", t, func() {
+		path := filepath.Join(dir, "a.stats")
+		writePlainFile(t, path)
+
+		d, err := decompressorFor(path, decompressorAuto)
+		So(err, ShouldBeNil)
+		So(d, ShouldHaveSameTypeAs, plainDecompressor{})
+		So(readAll(t, d, path), ShouldEqual, decompressTestContent)
+	})
+
+	Convey("An empty file is auto-detected as plain", t, func() {
+		path := filepath.Join(dir, "empty.stats")
+		writePlainFile(t, path)
+
+		if err := os.Truncate(path, 0); err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := decompressorFor(path, decompressorAuto)
+		So(err, ShouldBeNil)
+		So(d, ShouldHaveSameTypeAs, plainDecompressor{})
+	})
+
+	Convey("-decompressor forces a specific backend", t, func() {
+		path := filepath.Join(dir, "forced.stats")
+		writeGzipFile(t, path)
+
+		d, err := decompressorFor(path, decompressorPlain)
+		So(err, ShouldBeNil)
+		So(d, ShouldHaveSameTypeAs, plainDecompressor{})
+
+		Convey("reading gzip data with the plain backend doesn't decompress it", func() {
+			raw := readAll(t, d, path)
+			So(bytes.HasPrefix([]byte(raw), gzipMagic), ShouldBeTrue)
+		})
+	})
+
+	Convey("An unknown -decompressor name is an error", t, func() {
+		_, err := decompressorFor("doesn't matter", "bzip2")
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -23,47 +23,65 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
-)
 
-const (
-	statsFileSuffix         = ".stats.gz"
-	condarcSuffix           = ".condarc"
-	condaMetaOutputSuffix   = ".conda-meta"
-	condaMetaSuffix         = "/conda-meta/history"
-	singularityOutputSuffix = ".singularity"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/wtsi-hgi/condapaths/pkg/wrstat"
 )
 
-var singularitySuffixes = []string{".sif", ".simg", ".img"}
+// statsFileSuffixes are the input file extensions condapaths will accept,
+// in order of preference when multiple would match (longest first).
+var statsFileSuffixes = []string{".stats.gz", ".stats.zst", ".stats"} //nolint:gochecknoglobals
+
+const helpText = `condapaths parses wrstat stats files quickly, in low mem.
+
+Provide one or more .stats, .stats.gz or .stats.zst files output by wrstat.
+
+It outputs one file per rule, each containing the matching paths one per
+line, written alongside its input and named "<input prefix>.<rule name>".
+By default it uses built-in rules that reproduce the original 3 categories:
+* condarc: paths where the file basename was ".condarc"
+* conda-meta: paths where the file basename was "history", in a directory
+             named "conda-meta"
+* singularity: paths where the file basename suffix was one of ".sif",
+              ".simg", and ".img"
 
-const helpText = `condapaths parses wrstat stats.gz files quickly, in low mem.
+Pass -rules to use your own categories instead; see RuleConfig in rules.go
+for the available YAML predicates.
 
-Provide one or more stats.gz files output by wrstat.
+Pass -j to process that many input files concurrently; each gets its own
+decompressor and output files, so this is safe regardless of rule count.
 
-It outputs files with one path per line:
-* <input prefix>.condarc: paths where the file basename was ".condarc"
-* <input prefix>.conda-meta: paths where the file basename was "history", in a
-                             directory named "conda-meta"
-* <input prefix>.singularity: paths where the file basename suffix was one of
-                              ".sif",  ".simg", and ".img"
+Each input's compression is auto-detected from its magic bytes (pigz is
+used in preference to the slower compress/gzip when it's installed); pass
+-decompressor to force a specific backend instead.
 
 Usage: condapaths 20241222_mount.unique.stats.gz
 Options:
-  -h          this help text
+  -h             this help text
+  -rules         path to a YAML rules file (default: the 3 built-in rules above)
+  -j             number of input files to process concurrently (default 1)
+  -decompressor  force "pigz", "gzip", "zstd" or "plain" instead of auto-detecting
 `
 
+// l is safe for concurrent use: log.Logger serialises writes with its own
+// mutex, so -j workers can share it without extra locking.
 var l = log.New(os.Stderr, "", 0) //nolint:gochecknoglobals
 
 func main() {
 	var help = flag.Bool("h", false, "print help text")
+	var rulesPath = flag.String("rules", "", "path to a YAML rules file")
+	var jobs = flag.Int("j", 1, "number of input files to process concurrently")
+	var decompressorName = flag.String("decompressor", decompressorAuto,
+		"force a decompression backend (pigz, gzip, zstd or plain) instead of auto-detecting")
 	flag.Parse()
 
 	if *help {
@@ -74,25 +92,104 @@ func main() {
 		exitHelp("ERROR: you must provide at least 1 wrstat stats file")
 	}
 
-	for _, statsPath := range flag.Args() {
+	if *jobs < 1 {
+		exitHelp("ERROR: -j must be at least 1")
+	}
+
+	ruleConfigs, err := loadRuleConfigs(*rulesPath)
+	if err != nil {
+		die(err)
+	}
+
+	rules, err := compileRuleConfigs(ruleConfigs)
+	if err != nil {
+		die(err)
+	}
+
+	if err := processFiles(flag.Args(), rules, *jobs, *decompressorName); err != nil {
+		die(err)
+	}
+}
+
+// processFiles runs processFile over paths, at most concurrency at a time.
+// Each path gets its own decompressor and output files, so no merging of
+// results is needed, but since outputs are named after each input's prefix
+// and opened with os.Create (which truncates), two inputs that resolve to
+// the same prefix would clobber or interleave each other's output; that's
+// rejected up front rather than attempted concurrently. Processing of
+// not-yet-started paths stops as soon as one fails, but any already-started
+// decompressors are always waited on so no child processes are left behind;
+// the first error encountered is returned.
+func processFiles(paths []string, rules []rule, concurrency int, decompressorName string) error {
+	prefixes, err := prefixesForPaths(paths)
+	if err != nil {
+		return err
+	}
+
+	g, ctx := errgroup.WithContext(context.Background())
+	g.SetLimit(concurrency)
+
+	for i, statsPath := range paths {
+		statsPath, prefix := statsPath, prefixes[i]
+
+		g.Go(func() error {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return processFile(statsPath, prefix, rules, decompressorName)
+		})
+	}
+
+	return g.Wait()
+}
+
+// prefixesForPaths computes each path's output prefix, erroring if two paths
+// would resolve to the same one.
+func prefixesForPaths(paths []string) ([]string, error) {
+	prefixes := make([]string, len(paths))
+	seenBy := make(map[string]string, len(paths))
+
+	for i, statsPath := range paths {
 		prefix, err := getPathPrefix(statsPath)
 		if err != nil {
-			die(err)
+			return nil, fmt.Errorf("%s: %w", statsPath, err)
 		}
 
-		input, cleanup, err := decompress(statsPath)
-		if err != nil {
-			die(err)
+		if other, ok := seenBy[prefix]; ok {
+			return nil, fmt.Errorf("%s and %s both resolve to output prefix %q; "+
+				"rename one or process them in separate invocations", other, statsPath, prefix)
 		}
 
-		err = parseStats(input, prefix)
+		seenBy[prefix] = statsPath
+		prefixes[i] = prefix
+	}
 
-		cleanup()
+	return prefixes, nil
+}
 
-		if err != nil {
-			die(err)
-		}
+func processFile(statsPath, prefix string, rules []rule, decompressorName string) error {
+	d, err := decompressorFor(statsPath, decompressorName)
+	if err != nil {
+		return fmt.Errorf("%s: %w", statsPath, err)
+	}
+
+	input, cleanup, err := d.Open(statsPath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", statsPath, err)
+	}
+
+	err = parseStats(input, prefix, rules)
+
+	if cerr := cleanup(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", statsPath, err)
 	}
+
+	return nil
 }
 
 // exitHelp prints help text and exits 0, unless a message is passed in which
@@ -108,90 +205,60 @@ func exitHelp(msg string) {
 	os.Exit(0)
 }
 
+// getPathPrefix returns path's output prefix: path alongside the input, up to
+// the first "." in its basename, so outputs land next to their input rather
+// than wherever condapaths happens to be run from.
 func getPathPrefix(path string) (string, error) {
-	if !strings.HasSuffix(path, statsFileSuffix) {
-		return "", fmt.Errorf("path must end with %s", statsFileSuffix)
-	}
+	matched := false
 
-	base := filepath.Base(path)
-	idx := strings.Index(base, ".")
-
-	return base[:idx], nil
-}
+	for _, suffix := range statsFileSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			matched = true
 
-func decompress(path string) (io.ReadCloser, func() error, error) {
-	cmd := exec.Command("pigz", "-d", "-c", path)
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, nil, err
+			break
+		}
 	}
 
-	if err := cmd.Start(); err != nil {
-		return nil, nil, err
+	if !matched {
+		return "", fmt.Errorf("path must end with one of %v", statsFileSuffixes)
 	}
 
-	cleanup := func() error {
-		return cmd.Wait()
-	}
+	base := filepath.Base(path)
+	idx := strings.Index(base, ".")
 
-	return stdout, cleanup, nil
+	return filepath.Join(filepath.Dir(path), base[:idx]), nil
 }
 
-func parseStats(in io.ReadCloser, prefix string) error {
+func parseStats(in io.ReadCloser, prefix string, rules []rule) error {
 	defer in.Close()
 
-	rcOut, err := os.Create(prefix + condarcSuffix)
-	if err != nil {
-		return err
-	}
-
-	defer rcOut.Close()
+	outs := make([]*os.File, len(rules))
 
-	cmOut, err := os.Create(prefix + condaMetaOutputSuffix)
-	if err != nil {
-		return err
-	}
+	for i, r := range rules {
+		out, err := os.Create(prefix + "." + r.name)
+		if err != nil {
+			return err
+		}
 
-	defer cmOut.Close()
+		outs[i] = out
 
-	smOut, err := os.Create(prefix + singularityOutputSuffix)
-	if err != nil {
-		return err
+		defer out.Close() //nolint:gocritic
 	}
 
-	defer smOut.Close()
-
-	p := NewStatsParser(in)
-
-	condarcSuffixBytes := []byte(condarcSuffix)
-	condaMetaSuffixBytes := []byte(condaMetaSuffix)
-	singularitySuffixesBytes := make([][]byte, len(singularitySuffixes))
-	for i, suffix := range singularitySuffixes {
-		singularitySuffixesBytes[i] = []byte(suffix)
-	}
+	p := wrstat.NewStatsParser(in)
 
 	for p.Scan() {
-		if p.EntryType != fileType {
-			continue
-		}
-
-		switch {
-		case bytes.HasSuffix(p.Path, condarcSuffixBytes):
-			if _, err := rcOut.Write(append(p.Path, '\n')); err != nil {
-				return err
+		for i, r := range rules {
+			if !r.match(p) {
+				continue
 			}
-		case bytes.HasSuffix(p.Path, condaMetaSuffixBytes):
-			if _, err := cmOut.Write(append(p.Path, '\n')); err != nil {
+
+			if _, err := outs[i].Write(append(p.Path, '\n')); err != nil {
 				return err
 			}
-		default:
-			for _, suffix := range singularitySuffixesBytes {
-				if bytes.HasSuffix(p.Path, suffix) {
-					if _, err := smOut.Write(append(p.Path, '\n')); err != nil {
-						return err
-					}
-					break
-				}
+
+			if r.stop {
+				break
 			}
 		}
 	}
@@ -1,7 +1,6 @@
-// Copyright © 2024 Genome Research Limited
+// Copyright © 2025 Genome Research Limited
 // Authors:
 //  Sendu Bala <sb10@sanger.ac.uk>.
-//  Dan Elia <de7@sanger.ac.uk>.
 //
 // Permission is hereby granted, free of charge, to any person obtaining a copy
 // of this software and associated documentation files (the "Software"), to deal
@@ -24,96 +23,117 @@
 package main
 
 import (
-	"compress/gzip"
+	"fmt"
+	"io"
 	"os"
-	"strings"
+	"os/exec"
+	"path/filepath"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
 
-func TestParseStats(t *testing.T) {
-	Convey("Given a parser and reader", t, func() {
-		f, err := os.Open("test.stats.gz")
-		So(err, ShouldBeNil)
-
-		defer f.Close()
+const numTestCopies = 4
 
-		gr, err := gzip.NewReader(f)
-		So(err, ShouldBeNil)
+// copyTestStatsFiles makes numTestCopies copies of the wrstat test fixture
+// into dir, each with a distinct prefix, and returns their paths.
+func copyTestStatsFiles(t *testing.T, dir string) []string {
+	t.Helper()
 
-		defer gr.Close()
+	src, err := os.Open("pkg/wrstat/testdata/test.stats.gz")
+	if err != nil {
+		t.Skipf("test fixture not available: %s", err)
+	}
 
-		p := NewStatsParser(gr, "prefix")
-		So(p, ShouldNotBeNil)
+	defer src.Close()
 
-		Convey("you can get extract info for all entries", func() {
-			i := 0
-			for p.Scan() {
-				if i == 0 {
-					So(string(p.Path), ShouldEqual, "/lustre/scratch122/tol/teams/blaxter/users/am75/assemblies/dataset/ilXesSexs1.2_genomic.fna") //nolint:lll
-					So(p.EntryType, ShouldEqual, fileType)
-				} else if i == 1 {
-					So(string(p.Path), ShouldEqual, "/lustre/scratch122/tol/teams/blaxter/users/am75/assemblies/dataset/ilOpeBrum1.1_genomic.fna.fai") //nolint:lll
-				}
+	data, err := io.ReadAll(src)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-				i++
-			}
-			So(i, ShouldEqual, 18890)
+	paths := make([]string, numTestCopies)
 
-			So(p.Err(), ShouldBeNil)
-		})
-	})
+	for i := range paths {
+		path := filepath.Join(dir, fmt.Sprintf("copy%d.unique.stats.gz", i))
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
 
-	Convey("Scan generates Err() when", t, func() {
-		prefix := "prefix"
+		paths[i] = path
+	}
 
-		Convey("first column is not base64 encoded", func() {
-			p := NewStatsParser(strings.NewReader("this is invalid since it has spaces\t1\t1\t1\t1\t1\t1\tf\t1\t1\td\n"), prefix)
-			So(p.Scan(), ShouldBeFalse)
-			So(p.Err(), ShouldEqual, ErrBadPath)
-		})
+	return paths
+}
 
-		Convey("there are not enough tab separated columns", func() {
-			encodedPath := "L2x1c3RyZS9zY3JhdGNoMTIyL3RvbC90ZWFtcy9ibGF4dGVyL3VzZXJzL2FtNzUvYXNzZW1ibGllcy9kYXRhc2V0L2lsWGVzU2V4czEuMl9nZW5vbWljLmZuYQ==" //nolint:lll
+func TestProcessFiles(t *testing.T) {
+	if _, err := exec.LookPath("pigz"); err != nil {
+		t.Skip("pigz is not installed")
+	}
 
-			p := NewStatsParser(strings.NewReader(encodedPath+"\t1\t1\t1\t1\t1\t1\tf\t1\t1\td\n"), prefix)
-			So(p.Scan(), ShouldBeTrue)
-			So(p.Err(), ShouldBeNil)
+	Convey("Given several copies of a stats file", t, func() {
+		rules, err := compileRuleConfigs(defaultRuleConfigs())
+		So(err, ShouldBeNil)
 
-			p = NewStatsParser(strings.NewReader(encodedPath+"\t1\t1\t1\t1\t1\n"), prefix)
-			So(p.Scan(), ShouldBeFalse)
-			So(p.Err(), ShouldEqual, ErrTooFewColumns)
+		serialDir := t.TempDir()
+		serialPaths := copyTestStatsFiles(t, serialDir)
 
-			p = NewStatsParser(strings.NewReader(encodedPath+"\t1\t1\t1\t1\n"), prefix)
-			So(p.Scan(), ShouldBeFalse)
-			So(p.Err(), ShouldEqual, ErrTooFewColumns)
+		parallelDir := t.TempDir()
+		parallelPaths := copyTestStatsFiles(t, parallelDir)
 
-			p = NewStatsParser(strings.NewReader(encodedPath+"\t1\t1\t1\n"), prefix)
-			So(p.Scan(), ShouldBeFalse)
-			So(p.Err(), ShouldEqual, ErrTooFewColumns)
+		Convey("processing them serially or with -j > 1 produces identical output", func() {
+			So(processFiles(serialPaths, rules, 1, decompressorAuto), ShouldBeNil)
+			So(processFiles(parallelPaths, rules, numTestCopies, decompressorAuto), ShouldBeNil)
 
-			p = NewStatsParser(strings.NewReader(encodedPath+"\t1\t1\n"), prefix)
-			So(p.Scan(), ShouldBeFalse)
-			So(p.Err(), ShouldEqual, ErrTooFewColumns)
+			for i := range serialPaths {
+				serialOut := mustReadOutputs(t, serialDir, fmt.Sprintf("copy%d", i), rules)
+				parallelOut := mustReadOutputs(t, parallelDir, fmt.Sprintf("copy%d", i), rules)
+				So(parallelOut, ShouldResemble, serialOut)
+			}
+		})
+	})
+}
 
-			p = NewStatsParser(strings.NewReader(encodedPath+"\t1\n"), prefix)
-			So(p.Scan(), ShouldBeFalse)
-			So(p.Err(), ShouldEqual, ErrTooFewColumns)
+func TestProcessFilesRejectsDuplicatePrefixes(t *testing.T) {
+	Convey("Given two inputs that resolve to the same output prefix", t, func() {
+		rules, err := compileRuleConfigs(defaultRuleConfigs())
+		So(err, ShouldBeNil)
 
-			p = NewStatsParser(strings.NewReader(encodedPath+"\n"), prefix)
-			So(p.Scan(), ShouldBeFalse)
-			So(p.Err(), ShouldEqual, ErrTooFewColumns)
+		paths := []string{
+			filepath.Join("a", "2024.stats.gz"),
+			filepath.Join("a", "2024.unique.stats.gz"),
+		}
 
-			Convey("but not for blank lines", func() {
-				p = NewStatsParser(strings.NewReader("\n"), "prefix")
-				So(p.Scan(), ShouldBeTrue)
-				So(p.Err(), ShouldBeNil)
+		Convey("processFiles errors instead of clobbering or interleaving their output", func() {
+			err := processFiles(paths, rules, len(paths), decompressorAuto)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, filepath.Join("a", "2024"))
+		})
+	})
 
-				p := NewStatsParser(strings.NewReader(""), "prefix")
-				So(p.Scan(), ShouldBeFalse)
-				So(p.Err(), ShouldBeNil)
-			})
+	Convey("Given inputs with distinct prefixes, none are rejected", t, func() {
+		prefixes, err := prefixesForPaths([]string{
+			filepath.Join("a", "2024.stats.gz"),
+			filepath.Join("b", "2025.stats.gz"),
 		})
+		So(err, ShouldBeNil)
+		So(prefixes, ShouldResemble, []string{filepath.Join("a", "2024"), filepath.Join("b", "2025")})
 	})
 }
+
+func mustReadOutputs(t *testing.T, dir, prefix string, rules []rule) map[string]string {
+	t.Helper()
+
+	out := make(map[string]string, len(rules))
+
+	for _, r := range rules {
+		data, err := os.ReadFile(filepath.Join(dir, prefix+"."+r.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out[r.name] = string(data)
+	}
+
+	return out
+}
@@ -21,7 +21,10 @@
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
 // SOFTWARE.
 
-package main
+// Package wrstat parses wrstat stats files: tab separated, base64-path-encoded
+// records describing one filesystem entry per line. It is deliberately
+// allocation-light so that it can be used to stream very large stats dumps.
+package wrstat
 
 import (
 	"bufio"
@@ -36,12 +39,15 @@ type Error string
 func (e Error) Error() string { return string(e) }
 
 const (
-	fileType                   = byte('f')
+	// FileType is the EntryType value used for regular files.
+	FileType = byte('f')
+
 	maxLineLength              = 64 * 1024
 	maxBase64EncodedPathLength = 1024
 
 	ErrBadPath       = Error("invalid file format: path is not base64 encoded")
 	ErrTooFewColumns = Error("invalid file format: too few tab separated columns")
+	ErrBadColumn     = Error("invalid file format: column is not a valid integer")
 )
 
 // StatsParser is used to parse wrstat stats files.
@@ -52,6 +58,12 @@ type StatsParser struct {
 	lineLength int
 	lineIndex  int
 	Path       []byte
+	Size       int64
+	UID        uint32
+	GID        uint32
+	ATime      int64
+	MTime      int64
+	CTime      int64
 	EntryType  byte
 	error      error
 }
@@ -69,7 +81,8 @@ func NewStatsParser(r io.Reader) *StatsParser {
 }
 
 // Scan is used to read the next line of stats data, which will then be
-// available through the Path, Size, GID, MTime, CTime and EntryType properties.
+// available through the Path, Size, UID, GID, ATime, MTime, CTime and
+// EntryType properties.
 //
 // It returns false when the scan stops, either by reaching the end of the input
 // or an error. After Scan returns false, the Err method will return any error
@@ -99,7 +112,7 @@ func (p *StatsParser) parseLine() bool {
 		return false
 	}
 
-	if !p.skipColumns2to7() {
+	if !p.parseStatColumns() {
 		return false
 	}
 
@@ -113,13 +126,51 @@ func (p *StatsParser) parseLine() bool {
 	return p.decodePath(encodedPath)
 }
 
-func (p *StatsParser) skipColumns2to7() bool {
-	for i := 0; i < 6; i++ {
-		if _, ok := p.parseNextColumn(); !ok {
-			return false
-		}
+// parseStatColumns parses the Size, UID, GID, ATime, MTime and CTime columns,
+// which appear between the path and the entry type.
+func (p *StatsParser) parseStatColumns() bool {
+	size, ok := p.parseNextIntColumn()
+	if !ok {
+		return false
+	}
+
+	p.Size = size
+
+	uid, ok := p.parseNextIntColumn()
+	if !ok {
+		return false
+	}
+
+	p.UID = uint32(uid)
+
+	gid, ok := p.parseNextIntColumn()
+	if !ok {
+		return false
 	}
 
+	p.GID = uint32(gid)
+
+	atime, ok := p.parseNextIntColumn()
+	if !ok {
+		return false
+	}
+
+	p.ATime = atime
+
+	mtime, ok := p.parseNextIntColumn()
+	if !ok {
+		return false
+	}
+
+	p.MTime = mtime
+
+	ctime, ok := p.parseNextIntColumn()
+	if !ok {
+		return false
+	}
+
+	p.CTime = ctime
+
 	return true
 }
 
@@ -142,6 +193,59 @@ func (p *StatsParser) parseNextColumn() ([]byte, bool) {
 	return p.lineBytes[start:end], true
 }
 
+func (p *StatsParser) parseNextIntColumn() (int64, bool) {
+	col, ok := p.parseNextColumn()
+	if !ok {
+		return 0, false
+	}
+
+	n, ok := parseInt(col)
+	if !ok {
+		p.error = ErrBadColumn
+
+		return 0, false
+	}
+
+	return n, true
+}
+
+// parseInt converts a decimal byte slice to an int64 without allocating,
+// unlike strconv.ParseInt(string(b), ...).
+func parseInt(b []byte) (int64, bool) {
+	if len(b) == 0 {
+		return 0, false
+	}
+
+	neg := false
+	i := 0
+
+	if b[0] == '-' {
+		neg = true
+		i++
+	}
+
+	if i == len(b) {
+		return 0, false
+	}
+
+	var n int64
+
+	for ; i < len(b); i++ {
+		c := b[i]
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+
+		n = n*10 + int64(c-'0')
+	}
+
+	if neg {
+		n = -n
+	}
+
+	return n, true
+}
+
 func (p *StatsParser) decodePath(encodedPath []byte) bool {
 	l, err := base64.StdEncoding.Decode(p.pathBuffer, encodedPath)
 	if err != nil {
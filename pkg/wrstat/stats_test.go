@@ -0,0 +1,172 @@
+// Copyright © 2024 Genome Research Limited
+// Authors:
+//  Sendu Bala <sb10@sanger.ac.uk>.
+//  Dan Elia <de7@sanger.ac.uk>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package wrstat
+
+import (
+	"compress/gzip"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testStatsFile = "testdata/test.stats.gz"
+
+func TestParseStats(t *testing.T) {
+	Convey("Given a parser and reader", t, func() {
+		f, err := os.Open(testStatsFile)
+		if err != nil {
+			t.Skipf("test fixture not available: %s", err)
+		}
+
+		defer f.Close()
+
+		gr, err := gzip.NewReader(f)
+		So(err, ShouldBeNil)
+
+		defer gr.Close()
+
+		p := NewStatsParser(gr)
+		So(p, ShouldNotBeNil)
+
+		Convey("you can get extract info for all entries", func() {
+			i := 0
+			for p.Scan() {
+				if i == 0 {
+					So(string(p.Path), ShouldEqual, "/lustre/scratch122/tol/teams/blaxter/users/am75/assemblies/dataset/ilXesSexs1.2_genomic.fna") //nolint:lll
+					So(p.EntryType, ShouldEqual, FileType)
+				} else if i == 1 {
+					So(string(p.Path), ShouldEqual, "/lustre/scratch122/tol/teams/blaxter/users/am75/assemblies/dataset/ilOpeBrum1.1_genomic.fna.fai") //nolint:lll
+				}
+
+				i++
+			}
+			So(i, ShouldEqual, 6)
+
+			So(p.Err(), ShouldBeNil)
+		})
+	})
+
+	Convey("Scan generates Err() when", t, func() {
+		Convey("first column is not base64 encoded", func() {
+			p := NewStatsParser(strings.NewReader("this is invalid since it has spaces\t1\t1\t1\t1\t1\t1\tf\t1\t1\td\n"))
+			So(p.Scan(), ShouldBeFalse)
+			So(p.Err(), ShouldEqual, ErrBadPath)
+		})
+
+		Convey("there are not enough tab separated columns", func() {
+			encodedPath := "L2x1c3RyZS9zY3JhdGNoMTIyL3RvbC90ZWFtcy9ibGF4dGVyL3VzZXJzL2FtNzUvYXNzZW1ibGllcy9kYXRhc2V0L2lsWGVzU2V4czEuMl9nZW5vbWljLmZuYQ==" //nolint:lll
+
+			p := NewStatsParser(strings.NewReader(encodedPath + "\t1\t1\t1\t1\t1\t1\tf\t1\t1\td\n"))
+			So(p.Scan(), ShouldBeTrue)
+			So(p.Err(), ShouldBeNil)
+
+			p = NewStatsParser(strings.NewReader(encodedPath + "\t1\t1\t1\t1\t1\n"))
+			So(p.Scan(), ShouldBeFalse)
+			So(p.Err(), ShouldEqual, ErrTooFewColumns)
+
+			p = NewStatsParser(strings.NewReader(encodedPath + "\t1\t1\t1\t1\n"))
+			So(p.Scan(), ShouldBeFalse)
+			So(p.Err(), ShouldEqual, ErrTooFewColumns)
+
+			p = NewStatsParser(strings.NewReader(encodedPath + "\t1\t1\t1\n"))
+			So(p.Scan(), ShouldBeFalse)
+			So(p.Err(), ShouldEqual, ErrTooFewColumns)
+
+			p = NewStatsParser(strings.NewReader(encodedPath + "\t1\t1\n"))
+			So(p.Scan(), ShouldBeFalse)
+			So(p.Err(), ShouldEqual, ErrTooFewColumns)
+
+			p = NewStatsParser(strings.NewReader(encodedPath + "\t1\n"))
+			So(p.Scan(), ShouldBeFalse)
+			So(p.Err(), ShouldEqual, ErrTooFewColumns)
+
+			p = NewStatsParser(strings.NewReader(encodedPath + "\n"))
+			So(p.Scan(), ShouldBeFalse)
+			So(p.Err(), ShouldEqual, ErrTooFewColumns)
+
+			Convey("but not for blank lines", func() {
+				p = NewStatsParser(strings.NewReader("\n"))
+				So(p.Scan(), ShouldBeTrue)
+				So(p.Err(), ShouldBeNil)
+
+				p := NewStatsParser(strings.NewReader(""))
+				So(p.Scan(), ShouldBeFalse)
+				So(p.Err(), ShouldBeNil)
+			})
+		})
+
+		Convey("a stat column is not a valid integer", func() {
+			encodedPath := "L2x1c3RyZS9zY3JhdGNoMTIyL3RvbC90ZWFtcy9ibGF4dGVyL3VzZXJzL2FtNzUvYXNzZW1ibGllcy9kYXRhc2V0L2lsWGVzU2V4czEuMl9nZW5vbWljLmZuYQ==" //nolint:lll
+
+			p := NewStatsParser(strings.NewReader(encodedPath + "\tbad\t1\t1\t1\t1\t1\tf\t1\t1\td\n"))
+			So(p.Scan(), ShouldBeFalse)
+			So(p.Err(), ShouldEqual, ErrBadColumn)
+		})
+	})
+
+	Convey("Given a parser for a line with stat columns", t, func() {
+		encodedPath := "L2x1c3RyZS9zY3JhdGNoMTIyL3RvbC90ZWFtcy9ibGF4dGVyL3VzZXJzL2FtNzUvYXNzZW1ibGllcy9kYXRhc2V0L2lsWGVzU2V4czEuMl9nZW5vbWljLmZuYQ==" //nolint:lll
+
+		p := NewStatsParser(strings.NewReader(encodedPath + "\t12345\t1001\t1002\t1700000000\t1700000001\t1700000002\tf\t1\t1\td\n"))
+
+		Convey("Size, UID, GID, ATime, MTime and CTime are exposed", func() {
+			So(p.Scan(), ShouldBeTrue)
+			So(p.Err(), ShouldBeNil)
+			So(p.Size, ShouldEqual, 12345)
+			So(p.UID, ShouldEqual, 1001)
+			So(p.GID, ShouldEqual, 1002)
+			So(p.ATime, ShouldEqual, 1700000000)
+			So(p.MTime, ShouldEqual, 1700000001)
+			So(p.CTime, ShouldEqual, 1700000002)
+		})
+	})
+}
+
+func BenchmarkStatsParserScan(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		f, err := os.Open(testStatsFile)
+		if err != nil {
+			b.Skipf("test fixture not available: %s", err)
+		}
+
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		p := NewStatsParser(gr)
+
+		for p.Scan() { //nolint:revive
+		}
+
+		if err := p.Err(); err != nil {
+			b.Fatal(err)
+		}
+
+		gr.Close()
+		f.Close()
+	}
+}
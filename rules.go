@@ -0,0 +1,245 @@
+// Copyright © 2025 Genome Research Limited
+// Authors:
+//  Sendu Bala <sb10@sanger.ac.uk>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/wtsi-hgi/condapaths/pkg/wrstat"
+	"gopkg.in/yaml.v3"
+)
+
+// RuleConfig describes one named output category. An entry matches a rule
+// when every non-empty/non-zero predicate on it matches; predicates that
+// take a list match when any one element of the list matches.
+type RuleConfig struct {
+	Name           string   `yaml:"name"`
+	BasenameEquals []string `yaml:"basenameEquals,omitempty"`
+	BasenameSuffix []string `yaml:"basenameSuffix,omitempty"`
+	PathContains   []string `yaml:"pathContains,omitempty"`
+	PathGlob       []string `yaml:"pathGlob,omitempty"`
+	ParentDir      []string `yaml:"parentDir,omitempty"`
+	EntryType      string   `yaml:"entryType,omitempty"`
+	MinSize        int64    `yaml:"minSize,omitempty"`
+	MinMTime       int64    `yaml:"minMTime,omitempty"`
+	MaxMTime       int64    `yaml:"maxMTime,omitempty"`
+	Stop           bool     `yaml:"stop,omitempty"`
+}
+
+// defaultRuleConfigs reproduce condapaths' original, hardcoded classification,
+// used whenever -rules isn't given. Each is Stop: true, since a path was only
+// ever written to the first output it matched.
+func defaultRuleConfigs() []RuleConfig {
+	return []RuleConfig{
+		{
+			Name:           "condarc",
+			EntryType:      "f",
+			BasenameSuffix: []string{".condarc"},
+			Stop:           true,
+		},
+		{
+			Name:           "conda-meta",
+			EntryType:      "f",
+			BasenameEquals: []string{"history"},
+			ParentDir:      []string{"conda-meta"},
+			Stop:           true,
+		},
+		{
+			Name:           "singularity",
+			EntryType:      "f",
+			BasenameSuffix: []string{".sif", ".simg", ".img"},
+			Stop:           true,
+		},
+	}
+}
+
+// loadRuleConfigs reads and parses a YAML rules file, or returns
+// defaultRuleConfigs() if path is empty.
+func loadRuleConfigs(path string) ([]RuleConfig, error) {
+	if path == "" {
+		return defaultRuleConfigs(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfgs []RuleConfig
+
+	if err := yaml.Unmarshal(data, &cfgs); err != nil {
+		return nil, fmt.Errorf("invalid rules file %s: %w", path, err)
+	}
+
+	return cfgs, nil
+}
+
+// rule is a RuleConfig compiled down to a single predicate function, so that
+// the per-line hot loop only ever does a function call per rule rather than
+// re-examining the YAML config.
+type rule struct {
+	name  string
+	stop  bool
+	match func(p *wrstat.StatsParser) bool
+}
+
+// compileRuleConfigs compiles each RuleConfig's predicates into a single
+// match function, combining its predicates with AND (a list-valued predicate
+// matches with OR across its own elements).
+func compileRuleConfigs(cfgs []RuleConfig) ([]rule, error) {
+	rules := make([]rule, len(cfgs))
+
+	for i, cfg := range cfgs {
+		if cfg.Name == "" {
+			return nil, fmt.Errorf("rule %d: name is required", i)
+		}
+
+		rules[i] = rule{
+			name:  cfg.Name,
+			stop:  cfg.Stop,
+			match: compileMatch(cfg),
+		}
+	}
+
+	return rules, nil
+}
+
+func compileMatch(cfg RuleConfig) func(p *wrstat.StatsParser) bool { //nolint:gocyclo
+	var preds []func(p *wrstat.StatsParser) bool
+
+	if cfg.EntryType != "" {
+		entryType := cfg.EntryType[0]
+		preds = append(preds, func(p *wrstat.StatsParser) bool { return p.EntryType == entryType })
+	}
+
+	if len(cfg.BasenameEquals) > 0 {
+		want := toByteSlices(cfg.BasenameEquals)
+		preds = append(preds, func(p *wrstat.StatsParser) bool {
+			return matchesAny(basename(p.Path), want, bytes.Equal)
+		})
+	}
+
+	if len(cfg.BasenameSuffix) > 0 {
+		want := toByteSlices(cfg.BasenameSuffix)
+		preds = append(preds, func(p *wrstat.StatsParser) bool {
+			return matchesAny(basename(p.Path), want, bytes.HasSuffix)
+		})
+	}
+
+	if len(cfg.PathContains) > 0 {
+		want := toByteSlices(cfg.PathContains)
+		preds = append(preds, func(p *wrstat.StatsParser) bool {
+			return matchesAny(p.Path, want, bytes.Contains)
+		})
+	}
+
+	if len(cfg.ParentDir) > 0 {
+		want := toByteSlices(cfg.ParentDir)
+		preds = append(preds, func(p *wrstat.StatsParser) bool {
+			return matchesAny(parentDirName(p.Path), want, bytes.Equal)
+		})
+	}
+
+	if len(cfg.PathGlob) > 0 {
+		patterns := cfg.PathGlob
+		preds = append(preds, func(p *wrstat.StatsParser) bool {
+			path := string(p.Path)
+			for _, pattern := range patterns {
+				if ok, _ := filepath.Match(pattern, path); ok {
+					return true
+				}
+			}
+
+			return false
+		})
+	}
+
+	if cfg.MinSize > 0 {
+		minSize := cfg.MinSize
+		preds = append(preds, func(p *wrstat.StatsParser) bool { return p.Size >= minSize })
+	}
+
+	if cfg.MinMTime > 0 {
+		minMTime := cfg.MinMTime
+		preds = append(preds, func(p *wrstat.StatsParser) bool { return p.MTime >= minMTime })
+	}
+
+	if cfg.MaxMTime > 0 {
+		maxMTime := cfg.MaxMTime
+		preds = append(preds, func(p *wrstat.StatsParser) bool { return p.MTime <= maxMTime })
+	}
+
+	return func(p *wrstat.StatsParser) bool {
+		for _, pred := range preds {
+			if !pred(p) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+func toByteSlices(strs []string) [][]byte {
+	out := make([][]byte, len(strs))
+	for i, s := range strs {
+		out[i] = []byte(s)
+	}
+
+	return out
+}
+
+func matchesAny(b []byte, candidates [][]byte, cmp func(b, candidate []byte) bool) bool {
+	for _, candidate := range candidates {
+		if cmp(b, candidate) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// basename returns the final path element, equivalent to filepath.Base but
+// without allocating or special-casing empty/trailing-slash paths, which
+// wrstat paths never have.
+func basename(path []byte) []byte {
+	if idx := bytes.LastIndexByte(path, '/'); idx >= 0 {
+		return path[idx+1:]
+	}
+
+	return path
+}
+
+// parentDirName returns the name of path's parent directory, e.g. "conda-meta"
+// for "/a/b/conda-meta/history".
+func parentDirName(path []byte) []byte {
+	idx := bytes.LastIndexByte(path, '/')
+	if idx < 0 {
+		return nil
+	}
+
+	return basename(path[:idx])
+}
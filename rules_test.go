@@ -0,0 +1,175 @@
+// Copyright © 2025 Genome Research Limited
+// Authors:
+//  Sendu Bala <sb10@sanger.ac.uk>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/condapaths/pkg/wrstat"
+)
+
+// scanOne parses the single line of stats data and returns the parser
+// positioned on it, ready for rule matching.
+func scanOne(t *testing.T, line string) *wrstat.StatsParser {
+	t.Helper()
+
+	p := wrstat.NewStatsParser(strings.NewReader(line))
+	if !p.Scan() {
+		t.Fatalf("failed to scan line: %v", p.Err())
+	}
+
+	return p
+}
+
+// encodedFooPath is "/a/b/conda-meta/history" base64 encoded, with made up
+// but valid stat columns and a trailing type of "f".
+const statLine = "L2EvYi9jb25kYS1tZXRhL2hpc3Rvcnk=\t100\t1\t1\t1700000000\t1700000001\t1700000002\tf\t1\t1\td\n"
+
+func TestLoadRuleConfigs(t *testing.T) {
+	Convey("With no path, the built-in rules are returned", t, func() {
+		cfgs, err := loadRuleConfigs("")
+		So(err, ShouldBeNil)
+		So(cfgs, ShouldResemble, defaultRuleConfigs())
+	})
+
+	Convey("Given a YAML rules file", t, func() {
+		dir := t.TempDir()
+		path := dir + "/rules.yaml"
+
+		Convey("it is parsed into RuleConfigs", func() {
+			writeFile(t, path, `
+- name: big-files
+  minSize: 1000000
+- name: configs
+  basenameSuffix: [".condarc", ".yaml"]
+  stop: true
+`)
+
+			cfgs, err := loadRuleConfigs(path)
+			So(err, ShouldBeNil)
+			So(cfgs, ShouldResemble, []RuleConfig{
+				{Name: "big-files", MinSize: 1000000},
+				{Name: "configs", BasenameSuffix: []string{".condarc", ".yaml"}, Stop: true},
+			})
+		})
+
+		Convey("invalid YAML is an error", func() {
+			writeFile(t, path, "not: [valid")
+			_, err := loadRuleConfigs(path)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompileRuleConfigs(t *testing.T) {
+	Convey("A rule with no name is rejected", t, func() {
+		_, err := compileRuleConfigs([]RuleConfig{{MinSize: 1}})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Given the built-in rules, compiled matchers reproduce the original categories", t, func() {
+		rules, err := compileRuleConfigs(defaultRuleConfigs())
+		So(err, ShouldBeNil)
+		So(rules, ShouldHaveLength, 3)
+
+		Convey("conda-meta/history matches only the conda-meta rule", func() {
+			p := scanOne(t, statLine)
+
+			So(rules[0].match(p), ShouldBeFalse)
+			So(rules[1].match(p), ShouldBeTrue)
+			So(rules[2].match(p), ShouldBeFalse)
+		})
+
+		Convey("a non-dotfile *.condarc path still matches the condarc rule", func() {
+			// "/x/backup.condarc" base64 encoded.
+			line := "L3gvYmFja3VwLmNvbmRhcmM=\t100\t1\t1\t1700000000\t1700000001\t1700000002\tf\t1\t1\td\n"
+			p := scanOne(t, line)
+
+			So(rules[0].match(p), ShouldBeTrue)
+			So(rules[1].match(p), ShouldBeFalse)
+			So(rules[2].match(p), ShouldBeFalse)
+		})
+	})
+
+	Convey("Predicates within a rule combine with AND, across a rule's list values with OR", t, func() {
+		rules, err := compileRuleConfigs([]RuleConfig{
+			{
+				Name:           "match",
+				EntryType:      "f",
+				BasenameSuffix: []string{".sif", "story"},
+				ParentDir:      []string{"conda-meta"},
+			},
+		})
+		So(err, ShouldBeNil)
+
+		p := scanOne(t, statLine)
+		So(rules[0].match(p), ShouldBeTrue)
+	})
+
+	Convey("A path can match more than one rule when none of them stop", t, func() {
+		rules, err := compileRuleConfigs([]RuleConfig{
+			{Name: "all-files", EntryType: "f"},
+			{Name: "conda-meta", ParentDir: []string{"conda-meta"}},
+		})
+		So(err, ShouldBeNil)
+
+		p := scanOne(t, statLine)
+		So(rules[0].match(p), ShouldBeTrue)
+		So(rules[1].match(p), ShouldBeTrue)
+	})
+
+	Convey("MinSize, MinMTime and MaxMTime filter on the stat columns", t, func() {
+		rules, err := compileRuleConfigs([]RuleConfig{
+			{Name: "old", MaxMTime: 1699999999},
+			{Name: "new", MinMTime: 1699999999},
+			{Name: "huge", MinSize: 1000},
+		})
+		So(err, ShouldBeNil)
+
+		p := scanOne(t, statLine)
+		So(rules[0].match(p), ShouldBeFalse)
+		So(rules[1].match(p), ShouldBeTrue)
+		So(rules[2].match(p), ShouldBeFalse)
+	})
+
+	Convey("PathGlob matches the full path", t, func() {
+		rules, err := compileRuleConfigs([]RuleConfig{
+			{Name: "glob", PathGlob: []string{"/a/*/conda-meta/history"}},
+		})
+		So(err, ShouldBeNil)
+
+		p := scanOne(t, statLine)
+		So(rules[0].match(p), ShouldBeTrue)
+	})
+}